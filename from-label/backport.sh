@@ -11,12 +11,19 @@ if [ "$IS_DRAFT" = "true" ]; then
 	DRAFT_FLAG="--draft"
 fi
 
+DRAFT_ON_CONFLICT=${DRAFT_ON_CONFLICT:-false}
+INCLUDE_DIFF_STATS=${INCLUDE_DIFF_STATS:-false}
+LABEL_ON_CONFLICT=${LABEL_ON_CONFLICT:-}
+PR_BODY_MAX_LENGTH=${PR_BODY_MAX_LENGTH:-65536}
+COPY_REVIEWERS=${COPY_REVIEWERS:-false}
+
 if [ -z "$PULL_REQUEST" ] || [ -z "$TARGET_BRANCH" ] || [ -z "$REPO" ]; then
 	echo "Usage: $0 <PR number> <target branch> <owner/repo>" 1>&2
 	exit 1
 fi
 
 GITHUB_TRIGGERING_ACTOR=${GITHUB_TRIGGERING_ACTOR:-}
+GITHUB_RUN_ID=${GITHUB_RUN_ID:-}
 
 repo_name=$(echo "$REPO" | cut -d/ -f2)
 repo_owner=$(echo "$REPO" | cut -d/ -f1)
@@ -37,6 +44,7 @@ committed_something=""
 
 failed_commit=""
 skipped_commits=""
+conflicting_files=""
 
 for commit in $(GH_PAGER=  gh pr view "$pr_number" --json commits --jq '.commits[].oid'); do
 	if [ -n "$failed_commit" ]; then
@@ -51,6 +59,7 @@ $commit"
 		committed_something="true"
 	else
 		echo "Cherry-pick failed, skipping" 1>&2
+		conflicting_files=$(git diff --name-only --diff-filter=U)
 		git cherry-pick --abort
 		failed_commit="$commit"
 	fi
@@ -61,6 +70,11 @@ if [ -z "$committed_something" ]; then
 	git commit --allow-empty -m "Please amend this commit"
 fi
 
+if [ -n "$failed_commit" ] && [ "$DRAFT_ON_CONFLICT" = "true" ]; then
+	# Conflicts need to be resolved before asking for reviews
+	DRAFT_FLAG="--draft"
+fi
+
 git push -u origin "$branch_name"
 
 generated_by=""
@@ -71,6 +85,19 @@ EOF
 )
 fi
 
+run_link=""
+if [ -n "$GITHUB_RUN_ID" ]; then
+	run_link="See the workflow run at ${GITHUB_SERVER_URL:-https://github.com}/${GITHUB_REPOSITORY:-$REPO}/actions/runs/$GITHUB_RUN_ID."
+fi
+
+diff_stats=""
+if [ "$INCLUDE_DIFF_STATS" = "true" ]; then
+	shortstat=$(git diff --shortstat "origin/$TARGET_BRANCH" HEAD | sed 's/^ *//')
+	if [ -n "$shortstat" ]; then
+		diff_stats="> $shortstat"
+	fi
+fi
+
 failed=""
 if [ -n "$failed_commit" ]; then
 	failed=$(cat <<EOF
@@ -84,8 +111,21 @@ EOF
 )
 fi
 
+if [ -n "$conflicting_files" ]; then
+	failed=$(cat <<EOF
+$failed
+
+The following files had conflicts when cherry-picking $failed_commit:
+
+\`\`\`
+$conflicting_files
+\`\`\`
+EOF
+)
+fi
+
 title=$(echo "[$TARGET_BRANCH] $old_title")
-body=$(cat <<EOF
+body_header=$(cat <<EOF
 **Backport**
 
 Backport of $pr_link
@@ -98,16 +138,38 @@ cd $repo_name
 git switch $branch_name
 \`\`\`
 
+$diff_stats
+
 $failed
 
 $generated_by
+$run_link
 
 ---
-
-$old_body
 EOF
 )
 
+str_length() {
+	printf '%s' "$1" | jq -Rrs 'length'
+}
+
+# Only the original PR body is shortened, so the backport details are always kept
+header_length=$(( $(str_length "$body_header") + 2 ))
+if [ $(( header_length + $(str_length "$old_body") )) -gt "$PR_BODY_MAX_LENGTH" ]; then
+	truncated_notice="_[Body truncated to fit GitHub limits]_"
+	keep=$(( PR_BODY_MAX_LENGTH - header_length - $(str_length "$truncated_notice") - 2 ))
+	if [ "$keep" -lt 0 ]; then
+		keep=0
+	fi
+	old_body="$(printf '%s' "$old_body" | jq -Rrs --argjson n "$keep" '.[:$n]')
+
+$truncated_notice"
+fi
+
+body="$body_header
+
+$old_body"
+
 PR_URL=$(gh pr create \
   --title "$title" \
   --body "$body" \
@@ -117,3 +179,25 @@ PR_URL=$(gh pr create \
   $DRAFT_FLAG)
 echo "Created PR at $PR_URL"
 echo "pr-url=$PR_URL" >> $GITHUB_OUTPUT
+
+if [ -n "$failed_commit" ] && [ -n "$LABEL_ON_CONFLICT" ]; then
+	# Creating fails when the label already exists, which is fine
+	gh label create "$LABEL_ON_CONFLICT" --repo "$REPO" \
+		--description "Cherry-pick PR with conflicts to resolve manually" 2>/dev/null || true
+	if ! gh pr edit "$PR_URL" --add-label "$LABEL_ON_CONFLICT"; then
+		echo "Failed to add label $LABEL_ON_CONFLICT to $PR_URL" 1>&2
+	fi
+fi
+
+if [ "$COPY_REVIEWERS" = "true" ]; then
+	# Submitted reviews are no longer listed as requests, so the reviewers of
+	# the merged PR are included too. Teams can only be from the repo owner org.
+	reviewers=$(GH_PAGER= gh pr view "$pr_number" --json reviewRequests,latestReviews | jq -r --arg owner "$repo_owner" '
+		[(.reviewRequests[] | if .__typename == "Team" then "\($owner)/\(.slug | split("/") | last)" else .login end),
+		 (.latestReviews[].author.login)] | unique | .[]')
+	for reviewer in $reviewers; do
+		if ! gh pr edit "$PR_URL" --add-reviewer "$reviewer"; then
+			echo "Failed to request review from $reviewer on $PR_URL" 1>&2
+		fi
+	done
+fi